@@ -0,0 +1,227 @@
+package golden
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// DiffFormat selects how a golden-file mismatch is rendered.
+type DiffFormat int
+
+const (
+	// DiffPretty renders the mismatch using diffmatchpatch's inline,
+	// ANSI-colored diff. This is the default and matches the library's
+	// original behavior.
+	DiffPretty DiffFormat = iota
+	// DiffUnified renders the mismatch as a standard unified diff (the same
+	// shape as `git diff`/`diff -u`, with "--- golden/<path>" and
+	// "+++ actual/<path>" headers), which is easy to read in a terminal and
+	// can be fed to `patch`.
+	DiffUnified
+	// DiffJSON renders the mismatch as structured diff hunks encoded as
+	// JSON, for tooling that wants to post-process failures.
+	DiffJSON
+)
+
+// DiffHunkLine is one line of a DiffHunk.
+type DiffHunkLine struct {
+	// Kind is "context", "delete", or "insert".
+	Kind string `json:"kind"`
+	Text string `json:"text"`
+}
+
+// DiffHunk is one contiguous region of change (plus surrounding context)
+// between the golden file and the actual output.
+type DiffHunk struct {
+	GoldenStart int            `json:"goldenStart"`
+	GoldenCount int            `json:"goldenCount"`
+	ActualStart int            `json:"actualStart"`
+	ActualCount int            `json:"actualCount"`
+	Lines       []DiffHunkLine `json:"lines"`
+}
+
+// unifiedContext is the number of unchanged lines kept around each change,
+// matching `diff -u`'s default.
+const unifiedContext = 3
+
+// mismatchError builds the error CompareWithGolden-style helpers return for
+// a mismatch between expected (the golden file content) and actual (the
+// freshly produced content), rendering the diff according to
+// opts.DiffFormat and, if patch is non-empty, appending it as a JSON Patch
+// (see ModeJSONStructural/ModeJSONCanonical).
+func mismatchError(absPath, expected, actual string, opts CompareOptions, patch []JSONPatchOp) error {
+	var diffStr string
+	switch opts.DiffFormat {
+	case DiffUnified:
+		diffStr = unifiedDiff("golden/"+absPath, "actual/"+absPath, expected, actual)
+		if opts.WriteRejectFile {
+			rejPath := absPath + ".rej"
+			if err := ioutil.WriteFile(rejPath, []byte(actual), 0644); err != nil {
+				return fmt.Errorf("failed to write reject file %q: %w", rejPath, err)
+			}
+		}
+	case DiffJSON:
+		data, err := json.MarshalIndent(computeHunks(expected, actual), "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal diff hunks as JSON: %w", err)
+		}
+		diffStr = string(data)
+	default:
+		diffStr = diffText(expected, actual)
+	}
+
+	msg := fmt.Sprintf("mismatch of actual output and golden-file %q:\n%s\n", absPath, diffStr)
+	if len(patch) > 0 {
+		patchStr, err := formatJSONPatch(patch)
+		if err != nil {
+			return fmt.Errorf("failed to render JSON patch for golden file %q: %w", absPath, err)
+		}
+		msg += fmt.Sprintf("JSON patch (golden -> actual):\n%s\n", patchStr)
+	}
+	return errors.New(msg)
+}
+
+// unifiedDiff renders a standard unified diff (context = unifiedContext)
+// between expected and actual, using diffmatchpatch's line-mode diff so the
+// only third-party diffing algorithm the package depends on remains the
+// existing myers implementation.
+func unifiedDiff(fromLabel, toLabel, expected, actual string) string {
+	hunks := computeHunks(expected, actual)
+	if len(hunks) == 0 {
+		return ""
+	}
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", fromLabel, toLabel)
+	for _, h := range hunks {
+		fmt.Fprintf(&buf, "@@ -%d,%d +%d,%d @@\n", h.GoldenStart, h.GoldenCount, h.ActualStart, h.ActualCount)
+		for _, l := range h.Lines {
+			var prefix byte
+			switch l.Kind {
+			case "delete":
+				prefix = '-'
+			case "insert":
+				prefix = '+'
+			default:
+				prefix = ' '
+			}
+			buf.WriteByte(prefix)
+			buf.WriteString(l.Text)
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.String()
+}
+
+// computeHunks diffs expected and actual line by line (via diffmatchpatch's
+// line-mode helpers) and groups the changes into hunks with unifiedContext
+// lines of surrounding context, the same grouping `diff -u` uses.
+func computeHunks(expected, actual string) []DiffHunk {
+	dmp := diffmatchpatch.New()
+	a, b, lineArray := dmp.DiffLinesToChars(expected, actual)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(a, b, false), lineArray)
+
+	type positioned struct {
+		kind       string
+		text       string
+		goldenLine int
+		actualLine int
+	}
+	var lines []positioned
+	goldenLine, actualLine := 1, 1
+	for _, d := range diffs {
+		for _, text := range splitLinesKeepEmpty(d.Text) {
+			p := positioned{text: text}
+			switch d.Type {
+			case diffmatchpatch.DiffDelete:
+				p.kind = "delete"
+				p.goldenLine = goldenLine
+				goldenLine++
+			case diffmatchpatch.DiffInsert:
+				p.kind = "insert"
+				p.actualLine = actualLine
+				actualLine++
+			default:
+				p.kind = "context"
+				p.goldenLine = goldenLine
+				p.actualLine = actualLine
+				goldenLine++
+				actualLine++
+			}
+			lines = append(lines, p)
+		}
+	}
+
+	var changedIdx []int
+	for i, p := range lines {
+		if p.kind != "context" {
+			changedIdx = append(changedIdx, i)
+		}
+	}
+	if len(changedIdx) == 0 {
+		return nil
+	}
+
+	type span struct{ start, end int }
+	spans := []span{{start: changedIdx[0], end: changedIdx[0]}}
+	for _, idx := range changedIdx[1:] {
+		last := &spans[len(spans)-1]
+		if idx-last.end <= 2*unifiedContext+1 {
+			last.end = idx
+		} else {
+			spans = append(spans, span{start: idx, end: idx})
+		}
+	}
+
+	hunks := make([]DiffHunk, 0, len(spans))
+	for _, s := range spans {
+		start := s.start - unifiedContext
+		if start < 0 {
+			start = 0
+		}
+		end := s.end + unifiedContext
+		if end > len(lines)-1 {
+			end = len(lines) - 1
+		}
+
+		h := DiffHunk{}
+		for _, p := range lines[start : end+1] {
+			if p.kind != "insert" {
+				h.GoldenCount++
+				if h.GoldenStart == 0 {
+					h.GoldenStart = p.goldenLine
+				}
+			}
+			if p.kind != "delete" {
+				h.ActualCount++
+				if h.ActualStart == 0 {
+					h.ActualStart = p.actualLine
+				}
+			}
+			h.Lines = append(h.Lines, DiffHunkLine{Kind: p.kind, Text: p.text})
+		}
+		hunks = append(hunks, h)
+	}
+	return hunks
+}
+
+// splitLinesKeepEmpty splits s after every "\n", like strings.SplitAfter,
+// but drops the trailing empty element SplitAfter leaves behind when s ends
+// in "\n" and strips the newlines themselves since callers re-add them.
+func splitLinesKeepEmpty(s string) []string {
+	if s == "" {
+		return nil
+	}
+	parts := strings.SplitAfter(s, "\n")
+	if len(parts) > 0 && parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
+	}
+	for i, p := range parts {
+		parts[i] = strings.TrimSuffix(p, "\n")
+	}
+	return parts
+}
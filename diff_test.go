@@ -0,0 +1,61 @@
+package golden
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	t.Parallel()
+	expected := "a\nb\nc\nd\ne\n"
+	actual := "a\nb\nX\nd\ne\n"
+	out := unifiedDiff("golden/f.txt", "actual/f.txt", expected, actual)
+	require.Contains(t, out, "--- golden/f.txt\n+++ actual/f.txt\n")
+	require.Contains(t, out, "@@ -1,5 +1,5 @@\n")
+	require.Contains(t, out, "-c\n")
+	require.Contains(t, out, "+X\n")
+}
+
+func TestUnifiedDiffNoChange(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, "", unifiedDiff("a", "b", "same\n", "same\n"))
+}
+
+func TestComputeHunksJSON(t *testing.T) {
+	t.Parallel()
+	hunks := computeHunks("a\nb\n", "a\nB\n")
+	require.Len(t, hunks, 1)
+	require.Equal(t, 1, hunks[0].GoldenStart)
+	require.Equal(t, 2, hunks[0].GoldenCount)
+	require.Equal(t, 1, hunks[0].ActualStart)
+	require.Equal(t, 2, hunks[0].ActualCount)
+}
+
+func TestMismatchErrorWritesRejectFile(t *testing.T) {
+	t.Parallel()
+	f, err := ioutil.TempFile(".", "reject-*.golden")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	defer os.Remove(f.Name())
+	defer os.Remove(f.Name() + ".rej")
+
+	err = mismatchError(f.Name(), "expected\n", "actual\n", CompareOptions{
+		DiffFormat:      DiffUnified,
+		WriteRejectFile: true,
+	}, nil)
+	require.Error(t, err)
+
+	data, err := ioutil.ReadFile(f.Name() + ".rej")
+	require.NoError(t, err)
+	require.Equal(t, "actual\n", string(data))
+}
+
+func TestMismatchErrorDiffJSON(t *testing.T) {
+	t.Parallel()
+	err := mismatchError("f.golden.json", "a\nb\n", "a\nB\n", CompareOptions{DiffFormat: DiffJSON}, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `"goldenStart"`)
+}
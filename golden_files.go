@@ -27,17 +27,71 @@ type CompareOptions struct {
 	// locality comparison. In other words, that means we replace each UUID with
 	// a more generic "00000000-0000-0000-0000-000000000001",
 	// "00000000-0000-0000-0000-000000000002", ...,
-	// "00000000-0000-0000-0000-00000000000N" value.
+	// "00000000-0000-0000-0000-00000000000N" value. This is sugar for
+	// appending UUIDRedactor() to Redactors.
 	UUIDAgnostic bool
 	// Whether or not to ignore date/times when comparing or writing the golden
 	// file to disk.  We replace all RFC3339 time strings with
-	// "0001-01-01T00:00:00Z".
+	// "0001-01-01T00:00:00Z". This is sugar for appending DateTimeRedactor()
+	// to Redactors.
 	DateTimeAgnostic bool
+	// Redactors runs in addition to (and after) the UUIDAgnostic and
+	// DateTimeAgnostic built-ins, in order, over both the actual output and
+	// the golden file content before they are compared or written to disk.
+	// Use this to scrub things the two booleans don't cover, such as IP
+	// addresses, emails, bearer tokens, or arbitrary incrementing ids -- see
+	// UUIDRedactor, DateTimeRedactor, IPv4Redactor, IPv6Redactor,
+	// EmailRedactor, BearerTokenRedactor, CounterRedactor, RegexRedactor and
+	// JSONPathRedactor.
+	Redactors []Redactor
 	// Whether or not to call JSON marshall on the actual object before
 	// comparing it against the content of the golden file or writing to the
 	// golden file. If this is false, then we will treat the actual object as a
 	// []byte or string.
 	MarshalInputAsJSON bool
+	// Mode controls how a mismatch is decided when MarshalInputAsJSON is
+	// set. The zero value, ModeBytes, compares the formatted text verbatim
+	// as before; ModeJSONStructural and ModeJSONCanonical instead compare
+	// parsed JSON trees, so insignificant whitespace or map-key reordering
+	// (e.g. after an encoding/json version bump or a hand edit to the
+	// golden file) no longer causes spurious failures.
+	Mode CompareMode
+	// IncludeGlobs restricts CompareDirWithGolden to files whose path
+	// (relative to the scanned directory, slash-separated) matches at least
+	// one of these gopher-glob patterns. "**" matches any number of path
+	// segments. A nil or empty slice includes everything.
+	IncludeGlobs []string
+	// ExcludeGlobs removes files matching any of these gopher-glob patterns
+	// from a CompareDirWithGolden scan. Excludes are applied after
+	// IncludeGlobs.
+	ExcludeGlobs []string
+	// DiffFormat selects how a CompareWithGolden mismatch is rendered. The
+	// zero value, DiffPretty, keeps the original diffmatchpatch inline diff.
+	// CompareDirWithGolden and CompareFilesWithGolden ignore it and always
+	// render per-file mismatches as DiffPretty.
+	DiffFormat DiffFormat
+	// WriteRejectFile, when DiffFormat is DiffUnified, additionally dumps
+	// the actual output next to the golden file as "<goldenFile>.rej" on a
+	// mismatch, so it can be `cp`'d or `patch`'d into place without
+	// rerunning with -update.
+	WriteRejectFile bool
+	// FileMode controls the permissions a golden file is written with. The
+	// zero value defaults to 0644 rather than the world-writable
+	// os.ModePerm this package used to write with.
+	FileMode os.FileMode
+	// IgnorePaths elides the JSON value at each of these gitignore-style
+	// JSON Pointer patterns (e.g. "/data/attributes/createdAt",
+	// "/**/updatedAt", with a leading "!" re-including a path an earlier
+	// pattern matched) before comparison, replacing it with a stable
+	// "<ignored>" placeholder. Only applies when MarshalInputAsJSON is set.
+	IgnorePaths []string
+	// OnlyPaths is the inverse of IgnorePaths: when non-empty, every JSON
+	// value whose path does *not* match one of these patterns is elided.
+	// Matching a path keeps everything underneath it too, so OnlyPaths:
+	// []string{"/data"} keeps all of "/data", not just the literal pointer.
+	// IgnorePaths and OnlyPaths can be combined; a value is elided if
+	// either rule says so. Only applies when MarshalInputAsJSON is set.
+	OnlyPaths []string
 }
 
 // CompareWithGolden compares the actual object against the one from a
@@ -47,7 +101,7 @@ type CompareOptions struct {
 // first must run them with the -update flag in order to create an initial
 // golden version.
 func CompareWithGolden(t *testing.T, goldenFile string, actualObj interface{}, opts CompareOptions) {
-	if err := testableCompareWithGolden(*updateGoldenFiles, goldenFile, actualObj, opts); err != nil {
+	if err := testableCompare(*updateGoldenFiles, goldenFile, actualObj, opts); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -56,7 +110,7 @@ type stringer interface {
 	String() string
 }
 
-func testableCompareWithGolden(update bool, goldenFile string, actualObj interface{}, opts CompareOptions) error {
+func testableCompare(update bool, goldenFile string, actualObj interface{}, opts CompareOptions) error {
 	absPath, err := filepath.Abs(goldenFile)
 	if err != nil {
 		return fmt.Errorf("failed to get abosolute path for %q: %w", goldenFile, err)
@@ -80,30 +134,41 @@ func testableCompareWithGolden(update bool, goldenFile string, actualObj interfa
 			return fmt.Errorf("don't know how to convert type of object %[1]T to string: %+[1]v (consider enabling MarshalInputAsJSON option): %w", actualObj, err)
 		}
 	}
+	redactors := effectiveRedactors(opts)
+
 	if update {
+		mu := lockForPath(absPath)
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err := checkUpdateAllowed(); err != nil {
+			return err
+		}
+
 		// Make sure the directory exists where to write the file to
 		err := os.MkdirAll(filepath.Dir(absPath), os.FileMode(0777))
 		if err != nil {
 			return fmt.Errorf("failed to create directory (and potential parents dirs) to write golden file to: %w", err)
 		}
 
-		tmp := string(actual)
-		// Eliminate concrete UUIDs if requested. This makes adding changes to
-		// golden files much more easy in git.
-		if opts.UUIDAgnostic {
-			tmp, err = replaceUUIDs(tmp)
+		tmp, err := applyRedactors(string(actual), redactors)
+		if err != nil {
+			return fmt.Errorf("failed to redact actual object before writing golden file: %w", err)
+		}
+		if opts.MarshalInputAsJSON {
+			tmp, err = applyJSONPathFilters(tmp, opts)
 			if err != nil {
-				return fmt.Errorf("failed to replace UUIDs with more generic ones: %w", err)
+				return fmt.Errorf("failed to apply IgnorePaths/OnlyPaths before writing golden file: %w", err)
 			}
 		}
-		if opts.DateTimeAgnostic {
-			tmp, err = replaceTimes(tmp)
+		if opts.MarshalInputAsJSON && opts.Mode == ModeJSONCanonical {
+			canonical, err := canonicalizeJSON([]byte(tmp))
 			if err != nil {
-				return fmt.Errorf("failed to replace RFC3339 times with default time: %w", err)
+				return fmt.Errorf("failed to canonicalize golden file before writing: %w", err)
 			}
+			tmp = string(canonical)
 		}
-		err = ioutil.WriteFile(absPath, []byte(tmp), os.ModePerm)
-		if err != nil {
+		if err := atomicWriteFile(absPath, []byte(tmp), goldenFileMode(opts)); err != nil {
 			return fmt.Errorf("failed to update golden file %q: %w", absPath, err)
 		}
 	}
@@ -112,39 +177,50 @@ func testableCompareWithGolden(update bool, goldenFile string, actualObj interfa
 		return fmt.Errorf("failed to read golden file %q: %w", absPath, err)
 	}
 
-	expectedStr := string(expected)
-	actualStr := string(actual)
-	if opts.UUIDAgnostic {
-		expectedStr, err = replaceUUIDs(expectedStr)
+	expectedStr, err := applyRedactors(string(expected), redactors)
+	if err != nil {
+		return fmt.Errorf("failed to redact golden file content: %w", err)
+	}
+	actualStr, err := applyRedactors(string(actual), redactors)
+	if err != nil {
+		return fmt.Errorf("failed to redact actual object: %w", err)
+	}
+	if opts.MarshalInputAsJSON {
+		expectedStr, err = applyJSONPathFilters(expectedStr, opts)
 		if err != nil {
-			return fmt.Errorf("failed to replace UUIDs with more generic ones: %w", err)
+			return fmt.Errorf("failed to apply IgnorePaths/OnlyPaths to golden file content: %w", err)
 		}
-		actualStr, err = replaceUUIDs(actualStr)
+		actualStr, err = applyJSONPathFilters(actualStr, opts)
 		if err != nil {
-			return fmt.Errorf("failed to replace UUIDs with more generic ones: %w", err)
+			return fmt.Errorf("failed to apply IgnorePaths/OnlyPaths to actual object: %w", err)
 		}
 	}
-	if opts.DateTimeAgnostic {
-		expectedStr, err = replaceTimes(expectedStr)
+	if opts.MarshalInputAsJSON && opts.Mode != ModeBytes {
+		equal, patch, err := compareJSONStructural(expectedStr, actualStr)
 		if err != nil {
-			return fmt.Errorf("failed to replace RFC3339 times with default time: %w", err)
+			return fmt.Errorf("failed to compare golden file %q structurally: %w", absPath, err)
 		}
-		actualStr, err = replaceTimes(actualStr)
-		if err != nil {
-			return fmt.Errorf("failed to replace RFC3339 times with default time: %w", err)
+		if equal {
+			return nil
 		}
+		return mismatchError(absPath, expectedStr, actualStr, opts, patch)
 	}
-	if expectedStr != actualStr {
-		// log.Printf("ERROR: testableCompareWithGolden: expected value %v", expectedStr)
-		// log.Printf("ERROR: testableCompareWithGolden: actual value %v", actualStr)
 
-		dmp := diffmatchpatch.New()
-		diffs := dmp.DiffMain(expectedStr, actualStr, false)
-		return fmt.Errorf("mismatch of actual output and golden-file %q:\n %s \n", absPath, dmp.DiffPrettyText(diffs))
+	if expectedStr != actualStr {
+		return mismatchError(absPath, expectedStr, actualStr, opts, nil)
 	}
 	return nil
 }
 
+// diffText renders a human readable diff between expected and actual using
+// diffmatchpatch's pretty-printer. It is shared by every comparison helper
+// in this package so the failure output stays consistent.
+func diffText(expected, actual string) string {
+	dmp := diffmatchpatch.New()
+	diffs := dmp.DiffMain(expected, actual, false)
+	return dmp.DiffPrettyText(diffs)
+}
+
 // findUUIDs returns an array of uniq UUIDs that have been found in the given
 // string
 func findUUIDs(str string) ([]uuid.UUID, error) {
@@ -0,0 +1,165 @@
+package golden
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// CompareMode selects how CompareWithGolden interprets MarshalInputAsJSON
+// output when deciding whether actual and golden differ.
+type CompareMode int
+
+const (
+	// ModeBytes compares the marshaled bytes/string verbatim. This is the
+	// default and matches the library's original behavior.
+	ModeBytes CompareMode = iota
+	// ModeJSONStructural parses both sides as JSON and compares the
+	// resulting trees instead of their formatted text, tolerating
+	// insignificant whitespace and map-key ordering differences that would
+	// otherwise cause spurious failures.
+	ModeJSONStructural
+	// ModeJSONCanonical behaves like ModeJSONStructural and additionally
+	// rewrites the golden file, on -update, into a deterministic canonical
+	// form (sorted keys, fixed indentation) so that diffs in version control
+	// stay minimal. Number literals are kept as written; their formatting is
+	// not normalized (e.g. "1.0" and "1" stay distinct).
+	ModeJSONCanonical
+)
+
+// JSONPatchOp is a single RFC 6902 JSON Patch operation.
+type JSONPatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// compareJSONStructural parses expected and actual as JSON and reports
+// whether the resulting trees are equal. When they aren't, it also returns
+// an RFC 6902 JSON Patch describing how to turn expected into actual, for
+// use in diagnostics; deeply nested objects are dramatically more
+// actionable as a patch than as a character-level diff.
+func compareJSONStructural(expected, actual string) (equal bool, patch []JSONPatchOp, err error) {
+	var expectedVal, actualVal interface{}
+	if err := json.Unmarshal([]byte(expected), &expectedVal); err != nil {
+		return false, nil, fmt.Errorf("failed to parse golden file as JSON: %w", err)
+	}
+	if err := json.Unmarshal([]byte(actual), &actualVal); err != nil {
+		return false, nil, fmt.Errorf("failed to parse actual output as JSON: %w", err)
+	}
+	if reflect.DeepEqual(expectedVal, actualVal) {
+		return true, nil, nil
+	}
+	return false, diffJSON(expectedVal, actualVal), nil
+}
+
+// diffJSON computes an RFC 6902 JSON Patch describing how to turn from into
+// to. It is meant for diagnostics: unlike a patch generator optimizing for
+// a minimal patch, it always emits one operation per changed array index
+// rather than detecting insertions/deletions within a slice.
+func diffJSON(from, to interface{}) []JSONPatchOp {
+	return diffJSONAt("", from, to)
+}
+
+func diffJSONAt(path string, from, to interface{}) []JSONPatchOp {
+	if reflect.DeepEqual(from, to) {
+		return nil
+	}
+
+	fromMap, fromIsMap := from.(map[string]interface{})
+	toMap, toIsMap := to.(map[string]interface{})
+	if fromIsMap && toIsMap {
+		keySet := make(map[string]struct{}, len(fromMap)+len(toMap))
+		for k := range fromMap {
+			keySet[k] = struct{}{}
+		}
+		for k := range toMap {
+			keySet[k] = struct{}{}
+		}
+		keys := make([]string, 0, len(keySet))
+		for k := range keySet {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var ops []JSONPatchOp
+		for _, k := range keys {
+			childPath := path + "/" + escapeJSONPointerToken(k)
+			fv, fok := fromMap[k]
+			tv, tok := toMap[k]
+			switch {
+			case fok && !tok:
+				ops = append(ops, JSONPatchOp{Op: "remove", Path: childPath})
+			case !fok && tok:
+				ops = append(ops, JSONPatchOp{Op: "add", Path: childPath, Value: tv})
+			default:
+				ops = append(ops, diffJSONAt(childPath, fv, tv)...)
+			}
+		}
+		return ops
+	}
+
+	fromSlice, fromIsSlice := from.([]interface{})
+	toSlice, toIsSlice := to.([]interface{})
+	if fromIsSlice && toIsSlice {
+		var ops []JSONPatchOp
+		for i := 0; i < len(toSlice); i++ {
+			childPath := fmt.Sprintf("%s/%d", path, i)
+			if i >= len(fromSlice) {
+				ops = append(ops, JSONPatchOp{Op: "add", Path: childPath, Value: toSlice[i]})
+			} else {
+				ops = append(ops, diffJSONAt(childPath, fromSlice[i], toSlice[i])...)
+			}
+		}
+		// Remove surplus elements from the highest index down, so every
+		// path still exists when the operations are applied in order.
+		for i := len(fromSlice) - 1; i >= len(toSlice); i-- {
+			ops = append(ops, JSONPatchOp{Op: "remove", Path: fmt.Sprintf("%s/%d", path, i)})
+		}
+		return ops
+	}
+
+	return []JSONPatchOp{{Op: "replace", Path: path, Value: to}}
+}
+
+// escapeJSONPointerToken escapes a JSON Pointer reference token per RFC
+// 6901 ("~" -> "~0", "/" -> "~1").
+func escapeJSONPointerToken(token string) string {
+	token = strings.ReplaceAll(token, "~", "~0")
+	token = strings.ReplaceAll(token, "/", "~1")
+	return token
+}
+
+// formatJSONPatch renders ops as an indented JSON array for inclusion in a
+// mismatch error message.
+func formatJSONPatch(ops []JSONPatchOp) (string, error) {
+	data, err := json.MarshalIndent(ops, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal JSON patch: %w", err)
+	}
+	return string(data), nil
+}
+
+// canonicalizeJSON re-serializes data in a deterministic canonical form:
+// object keys sorted alphabetically (encoding/json's default behavior when
+// marshaling a map[string]interface{}), so repeated -update runs produce
+// byte-identical output and diffs in version control stay minimal. Numbers
+// are decoded with UseNumber and kept as their original literal (rather than
+// round-tripped through float64), so integers beyond 2^53 and exponent
+// notation like "1e+20" survive canonicalization unchanged.
+func canonicalizeJSON(data []byte) ([]byte, error) {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON for canonicalization: %w", err)
+	}
+	out, err := marshalIndentNoEscapeHTML(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal canonical JSON: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,60 @@
+package golden
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareJSONStructuralToleratesFormatting(t *testing.T) {
+	t.Parallel()
+	equal, patch, err := compareJSONStructural(
+		`{"a": 1, "b": {"c": 2}}`,
+		"{\n  \"b\": {\"c\":2},\n  \"a\":1\n}\n",
+	)
+	require.NoError(t, err)
+	require.True(t, equal)
+	require.Nil(t, patch)
+}
+
+func TestCompareJSONStructuralReportsPatch(t *testing.T) {
+	t.Parallel()
+	equal, patch, err := compareJSONStructural(
+		`{"a": 1, "b": 2, "c": [1, 2]}`,
+		`{"a": 1, "b": 3, "c": [1, 2, 3]}`,
+	)
+	require.NoError(t, err)
+	require.False(t, equal)
+	require.Equal(t, []JSONPatchOp{
+		{Op: "replace", Path: "/b", Value: float64(3)},
+		{Op: "add", Path: "/c/2", Value: float64(3)},
+	}, patch)
+}
+
+func TestCompareJSONStructuralInvalidJSON(t *testing.T) {
+	t.Parallel()
+	_, _, err := compareJSONStructural("not json", `{}`)
+	require.Error(t, err)
+}
+
+func TestCanonicalizeJSON(t *testing.T) {
+	t.Parallel()
+	// Keys sort alphabetically, but number literals are preserved verbatim
+	// (not round-tripped through float64), so "1.0" stays "1.0" rather than
+	// converging with a bare "1" -- see canonicalizeJSON's doc comment.
+	out, err := canonicalizeJSON([]byte(`{"b":2,"a":1.0}`))
+	require.NoError(t, err)
+	require.Equal(t, "{\n  \"a\": 1.0,\n  \"b\": 2\n}", string(out))
+}
+
+func TestCanonicalizeJSONPreservesLargeIntegersAndExponents(t *testing.T) {
+	t.Parallel()
+	out, err := canonicalizeJSON([]byte(`{"big":123456789012345678901234567890,"exp":1e+20}`))
+	require.NoError(t, err)
+	require.Equal(t, "{\n  \"big\": 123456789012345678901234567890,\n  \"exp\": 1e+20\n}", string(out))
+}
+
+func TestEscapeJSONPointerToken(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, "a~01~1b", escapeJSONPointerToken("a~1/b"))
+}
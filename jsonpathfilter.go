@@ -0,0 +1,168 @@
+package golden
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ignoredPlaceholder replaces any JSON value matched by
+// CompareOptions.IgnorePaths/OnlyPaths before comparison or before a golden
+// file is written.
+const ignoredPlaceholder = "<ignored>"
+
+// pathPattern is one compiled gitignore-style pattern over JSON Pointer
+// path segments, modeled on go-git's plumbing/format/gitignore: a leading
+// "!" negates the pattern (re-including a path an earlier pattern matched),
+// and "**" stands for any number of segments.
+type pathPattern struct {
+	negate bool
+	parts  []string
+}
+
+func compilePathPattern(pattern string) pathPattern {
+	negate := strings.HasPrefix(pattern, "!")
+	if negate {
+		pattern = pattern[1:]
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+	return pathPattern{negate: negate, parts: strings.Split(pattern, "/")}
+}
+
+func compilePathPatterns(patterns []string) []pathPattern {
+	compiled := make([]pathPattern, len(patterns))
+	for i, p := range patterns {
+		compiled[i] = compilePathPattern(p)
+	}
+	return compiled
+}
+
+// matches reports whether the JSON Pointer path described by parts (e.g.
+// []string{"data", "attributes", "createdAt"}) matches this pattern.
+func (p pathPattern) matches(parts []string) bool {
+	ok, _ := matchGlobParts(p.parts, parts)
+	return ok
+}
+
+// pathMatchState reports whether parts is matched by patterns, applying
+// gitignore semantics: patterns are evaluated in order and the last one
+// that matches wins, so a later "!pattern" can re-include a path an earlier
+// pattern excluded.
+func pathMatchState(parts []string, patterns []pathPattern) bool {
+	matched := false
+	for _, p := range patterns {
+		if p.matches(parts) {
+			matched = !p.negate
+		}
+	}
+	return matched
+}
+
+// applyJSONPathFilters parses str as JSON and replaces every node whose
+// JSON Pointer path matches opts.IgnorePaths, or doesn't match
+// opts.OnlyPaths (when OnlyPaths is non-empty), with ignoredPlaceholder. It
+// is a no-op if both lists are empty.
+func applyJSONPathFilters(str string, opts CompareOptions) (string, error) {
+	if len(opts.IgnorePaths) == 0 && len(opts.OnlyPaths) == 0 {
+		return str, nil
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(str), &v); err != nil {
+		return "", fmt.Errorf("failed to parse buffer as JSON to apply IgnorePaths/OnlyPaths: %w", err)
+	}
+	ignore := compilePathPatterns(opts.IgnorePaths)
+	only := compilePathPatterns(opts.OnlyPaths)
+	filtered := filterJSONNode(v, nil, ignore, only, false)
+	out, err := marshalIndentNoEscapeHTML(filtered)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal filtered JSON: %w", err)
+	}
+	return string(out), nil
+}
+
+// couldMatchDescendant reports whether path is a possible ancestor of some
+// JSON Pointer matched by one of patterns, i.e. whether extending path with
+// more segments could still land on a match. Without this, a nested
+// OnlyPaths target like "/data/id" would never be reached: the "/data"
+// ancestor doesn't itself match the pattern, so it would be elided outright
+// before recursion ever got to "/data/id".
+func couldMatchDescendant(path []string, patterns []pathPattern) bool {
+	for _, p := range patterns {
+		if matchGlobPrefix(p.parts, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlobPrefix is matchGlobParts' counterpart for partial paths: it
+// reports whether name could be a prefix of some longer name that matches
+// pattern, rather than requiring name to match pattern in full. A "**" in
+// pattern can absorb all of name's remaining segments, so once the segments
+// before it match, every longer path is a possible match: a pattern like
+// "/**/id" keeps every object and array a candidate, and filterJSONNode
+// still elides the scalar leaves that don't match it.
+func matchGlobPrefix(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchGlobPrefix(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return true
+	}
+	ok, err := filepath.Match(pattern[0], name[0])
+	if err != nil || !ok {
+		return false
+	}
+	return matchGlobPrefix(pattern[1:], name[1:])
+}
+
+// filterJSONNode walks v, eliding nodes matched by ignore or not matched by
+// only. onlyForced is true once an ancestor has already matched one of the
+// only patterns: matching a path implies every value underneath it is kept
+// too, the same way an IgnorePaths match elides a whole subtree rather than
+// just the matched node itself, so e.g. OnlyPaths: ["/data"] keeps all of
+// "/data" rather than only the literal "/data" pointer.
+func filterJSONNode(v interface{}, path []string, ignore, only []pathPattern, onlyForced bool) interface{} {
+	elide := len(ignore) > 0 && pathMatchState(path, ignore)
+
+	selfMatchesOnly := onlyForced || pathMatchState(path, only)
+	if len(only) > 0 && !selfMatchesOnly {
+		_, isMap := v.(map[string]interface{})
+		_, isSlice := v.([]interface{})
+		if !(isMap || isSlice) || !couldMatchDescendant(path, only) {
+			elide = true
+		}
+	}
+	if elide && len(path) > 0 {
+		return ignoredPlaceholder
+	}
+
+	childForced := onlyForced || selfMatchesOnly
+
+	switch node := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(node))
+		for k, child := range node {
+			out[k] = filterJSONNode(child, append(append([]string{}, path...), k), ignore, only, childForced)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(node))
+		for i, child := range node {
+			out[i] = filterJSONNode(child, append(append([]string{}, path...), strconv.Itoa(i)), ignore, only, childForced)
+		}
+		return out
+	default:
+		return v
+	}
+}
@@ -0,0 +1,94 @@
+package golden
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const jsonPathFilterInput = `{
+  "data": {
+    "id": "abc-123",
+    "attributes": {
+      "createdAt": "2020-01-01T00:00:00Z",
+      "updatedAt": "2020-01-02T00:00:00Z",
+      "name": "demo"
+    }
+  }
+}`
+
+func TestApplyJSONPathFiltersIgnorePaths(t *testing.T) {
+	t.Parallel()
+	out, err := applyJSONPathFilters(jsonPathFilterInput, CompareOptions{
+		MarshalInputAsJSON: true,
+		IgnorePaths:        []string{"/**/createdAt", "/**/updatedAt"},
+	})
+	require.NoError(t, err)
+	require.Contains(t, out, `"createdAt": "<ignored>"`)
+	require.Contains(t, out, `"updatedAt": "<ignored>"`)
+	require.Contains(t, out, `"name": "demo"`)
+	require.Contains(t, out, `"id": "abc-123"`)
+}
+
+func TestApplyJSONPathFiltersOnlyPaths(t *testing.T) {
+	t.Parallel()
+	out, err := applyJSONPathFilters(jsonPathFilterInput, CompareOptions{
+		MarshalInputAsJSON: true,
+		OnlyPaths:          []string{"/data/id"},
+	})
+	require.NoError(t, err)
+	require.Contains(t, out, `"id": "abc-123"`)
+	require.Contains(t, out, `"attributes": "<ignored>"`)
+}
+
+func TestApplyJSONPathFiltersOnlyPathsDoubleStar(t *testing.T) {
+	t.Parallel()
+	out, err := applyJSONPathFilters(jsonPathFilterInput, CompareOptions{
+		MarshalInputAsJSON: true,
+		OnlyPaths:          []string{"/**/id"},
+	})
+	require.NoError(t, err)
+	require.Contains(t, out, `"id": "abc-123"`)
+	require.Contains(t, out, `"createdAt": "<ignored>"`)
+	require.Contains(t, out, `"updatedAt": "<ignored>"`)
+	require.Contains(t, out, `"name": "<ignored>"`)
+}
+
+func TestApplyJSONPathFiltersOnlyPathsMatchImpliesDescendants(t *testing.T) {
+	t.Parallel()
+	out, err := applyJSONPathFilters(jsonPathFilterInput, CompareOptions{
+		MarshalInputAsJSON: true,
+		OnlyPaths:          []string{"/data"},
+	})
+	require.NoError(t, err)
+	require.Contains(t, out, `"id": "abc-123"`)
+	require.Contains(t, out, `"createdAt": "2020-01-01T00:00:00Z"`)
+	require.Contains(t, out, `"updatedAt": "2020-01-02T00:00:00Z"`)
+	require.Contains(t, out, `"name": "demo"`)
+	require.NotContains(t, out, ignoredPlaceholder)
+}
+
+func TestApplyJSONPathFiltersNegation(t *testing.T) {
+	t.Parallel()
+	out, err := applyJSONPathFilters(jsonPathFilterInput, CompareOptions{
+		MarshalInputAsJSON: true,
+		IgnorePaths:        []string{"/data/*", "!/data/id"},
+	})
+	require.NoError(t, err)
+	require.Contains(t, out, `"id": "abc-123"`)
+	require.Contains(t, out, `"attributes": "<ignored>"`)
+}
+
+func TestApplyJSONPathFiltersNoPatternsIsNoop(t *testing.T) {
+	t.Parallel()
+	out, err := applyJSONPathFilters(jsonPathFilterInput, CompareOptions{MarshalInputAsJSON: true})
+	require.NoError(t, err)
+	require.Equal(t, jsonPathFilterInput, out)
+}
+
+func TestPathMatchState(t *testing.T) {
+	t.Parallel()
+	patterns := compilePathPatterns([]string{"/data/**", "!/data/id"})
+	require.True(t, pathMatchState([]string{"data", "attributes"}, patterns))
+	require.False(t, pathMatchState([]string{"data", "id"}, patterns))
+}
@@ -0,0 +1,108 @@
+package golden
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// splitJSONPointer splits an RFC 6901 JSON Pointer such as
+// "/data/attributes/id" into its unescaped reference tokens ("~1" decodes
+// to "/", "~0" decodes to "~"). An empty pointer denotes the whole
+// document and splits to no tokens.
+func splitJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("JSON pointer %q must start with \"/\"", pointer)
+	}
+	rawTokens := strings.Split(pointer[1:], "/")
+	tokens := make([]string, len(rawTokens))
+	for i, t := range rawTokens {
+		t = strings.ReplaceAll(t, "~1", "/")
+		t = strings.ReplaceAll(t, "~0", "~")
+		tokens[i] = t
+	}
+	return tokens, nil
+}
+
+// setJSONPointer walks v -- the result of json.Unmarshal into interface{} --
+// following pointer and overwrites the value found there with replacement.
+// It reports whether pointer resolved to an existing value. The empty
+// pointer (the whole document) is never replaced and reports false.
+func setJSONPointer(v interface{}, pointer string, replacement interface{}) (bool, error) {
+	tokens, err := splitJSONPointer(pointer)
+	if err != nil {
+		return false, err
+	}
+	if len(tokens) == 0 {
+		return false, nil
+	}
+	return setJSONPointerTokens(v, tokens, replacement), nil
+}
+
+func setJSONPointerTokens(v interface{}, tokens []string, replacement interface{}) bool {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		key := tokens[0]
+		child, ok := node[key]
+		if !ok {
+			return false
+		}
+		if len(tokens) == 1 {
+			node[key] = replacement
+			return true
+		}
+		return setJSONPointerTokens(child, tokens[1:], replacement)
+	case []interface{}:
+		idx, err := strconv.Atoi(tokens[0])
+		if err != nil || idx < 0 || idx >= len(node) {
+			return false
+		}
+		if len(tokens) == 1 {
+			node[idx] = replacement
+			return true
+		}
+		return setJSONPointerTokens(node[idx], tokens[1:], replacement)
+	default:
+		return false
+	}
+}
+
+// applyJSONPointerRedaction parses str as JSON, overwrites the value at
+// pointer with replacement (a no-op if pointer doesn't resolve), and
+// re-serializes the document with the same indentation CompareWithGolden
+// uses elsewhere.
+func applyJSONPointerRedaction(str, pointer, replacement string) (string, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(str), &v); err != nil {
+		return "", fmt.Errorf("failed to parse buffer as JSON: %w", err)
+	}
+	if _, err := setJSONPointer(v, pointer, replacement); err != nil {
+		return "", err
+	}
+	out, err := marshalIndentNoEscapeHTML(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal redacted JSON: %w", err)
+	}
+	return string(out), nil
+}
+
+// marshalIndentNoEscapeHTML indents v like json.MarshalIndent(v, "", "  "),
+// but without HTML-escaping "<", ">" and "&". Golden files and redaction
+// placeholders (e.g. "<redacted>", "<ignored>") are not HTML, so escaping
+// them would both make placeholders unreadable ("<" and ">" written as \u
+// escapes) and mangle any real data containing those characters.
+func marshalIndentNoEscapeHTML(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
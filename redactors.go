@@ -0,0 +1,260 @@
+package golden
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Redactor rewrites volatile substrings of a buffer -- a UUID, a timestamp,
+// a request id -- before it participates in golden-file comparison or is
+// written to disk, so that values which legitimately change between runs
+// don't cause spurious mismatches.
+type Redactor interface {
+	// Apply returns str with the redactor's target substrings rewritten.
+	Apply(str string) (string, error)
+	// Name identifies the redactor for diagnostics, e.g. in error messages
+	// produced when Apply fails.
+	Name() string
+}
+
+// effectiveRedactors returns the list of redactors opts wants applied, with
+// the UUIDAgnostic/DateTimeAgnostic sugar fields expanded to their built-in
+// Redactor first, followed by opts.Redactors in the order given.
+func effectiveRedactors(opts CompareOptions) []Redactor {
+	redactors := make([]Redactor, 0, len(opts.Redactors)+2)
+	if opts.UUIDAgnostic {
+		redactors = append(redactors, UUIDRedactor())
+	}
+	if opts.DateTimeAgnostic {
+		redactors = append(redactors, DateTimeRedactor())
+	}
+	return append(redactors, opts.Redactors...)
+}
+
+// applyRedactors runs each redactor over str in order, feeding the result of
+// one into the next.
+func applyRedactors(str string, redactors []Redactor) (string, error) {
+	for _, r := range redactors {
+		var err error
+		str, err = r.Apply(str)
+		if err != nil {
+			return "", fmt.Errorf("redactor %s failed: %w", r.Name(), err)
+		}
+	}
+	return str, nil
+}
+
+// funcRedactor adapts a plain redaction function to the Redactor interface.
+type funcRedactor struct {
+	name string
+	fn   func(string) (string, error)
+}
+
+func (f *funcRedactor) Name() string                     { return f.name }
+func (f *funcRedactor) Apply(str string) (string, error) { return f.fn(str) }
+
+// UUIDRedactor replaces every UUID in a buffer with
+// "00000000-0000-0000-0000-000000000001",
+// "00000000-0000-0000-0000-000000000002", ..., numbered by order of first
+// appearance. It is the Redactor equivalent of CompareOptions.UUIDAgnostic.
+func UUIDRedactor() Redactor {
+	return &funcRedactor{name: "UUIDRedactor", fn: replaceUUIDs}
+}
+
+// DateTimeRedactor replaces every RFC3339 and RFC7232 date/time in a buffer
+// with a fixed placeholder time. It is the Redactor equivalent of
+// CompareOptions.DateTimeAgnostic.
+func DateTimeRedactor() Redactor {
+	return &funcRedactor{name: "DateTimeRedactor", fn: replaceTimes}
+}
+
+// renumberMatches finds all matches of re in str and replaces each distinct
+// match, in order of first appearance, with fmt.Sprintf(format, n) where n
+// starts at 1. This is the same locality-preserving numbering replaceUUIDs
+// uses, generalized to an arbitrary pattern.
+//
+// Unlike replaceUUIDs, the replacements here (e.g. "10.0.0.2") can
+// themselves look like a match of re, so replacing one match at a time with
+// N sequential strings.Replace passes -- as replaceUUIDs does -- would let
+// an earlier replacement collide with a later match. Replace in a single
+// pass instead.
+func renumberMatches(str string, re *regexp.Regexp, format string) string {
+	return renumberValidMatches(str, re, format, func(string) bool { return true })
+}
+
+// renumberValidMatches behaves like renumberMatches, but only renumbers a
+// match of re if validate also accepts it, leaving every other match
+// untouched. This lets a redactor use a loose, easy-to-anchor regexp to find
+// candidates and a separate, precise check (e.g. net.ParseIP) to decide
+// which candidates are real.
+func renumberValidMatches(str string, re *regexp.Regexp, format string, validate func(string) bool) string {
+	seen := map[string]int{}
+	return re.ReplaceAllStringFunc(str, func(m string) string {
+		if !validate(m) {
+			return m
+		}
+		n, ok := seen[m]
+		if !ok {
+			n = len(seen) + 1
+			seen[m] = n
+		}
+		return fmt.Sprintf(format, n)
+	})
+}
+
+// regexRenumberRedactor is a Redactor built from a fixed regexp whose
+// distinct matches are renumbered in order of first appearance. If validate
+// is non-nil, a regexp match is only renumbered when validate also accepts
+// it; this lets the regexp over-match a superset of candidates and leave the
+// precise decision to validate (see IPv6Redactor).
+type regexRenumberRedactor struct {
+	name     string
+	re       *regexp.Regexp
+	format   string
+	validate func(string) bool
+}
+
+func (r *regexRenumberRedactor) Name() string { return r.name }
+
+func (r *regexRenumberRedactor) Apply(str string) (string, error) {
+	if r.validate == nil {
+		return renumberMatches(str, r.re, r.format), nil
+	}
+	return renumberValidMatches(str, r.re, r.format, r.validate), nil
+}
+
+var ipv4Pattern = regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.){3}(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\b`)
+
+// IPv4Redactor replaces every IPv4 address with "10.0.0.1", "10.0.0.2", ...,
+// numbered by order of first appearance.
+func IPv4Redactor() Redactor {
+	return &regexRenumberRedactor{name: "IPv4Redactor", re: ipv4Pattern, format: "10.0.0.%d"}
+}
+
+// ipv6CandidatePattern matches any maximal run of hex digits and colons
+// containing at least one colon. "\b" can't anchor a "::"-compressed
+// address (both sides of the leading ":" are non-word characters, so no
+// word boundary exists there at all), so instead of trying to describe a
+// valid IPv6 address in the regexp itself, this deliberately over-matches;
+// ipv6Valid then decides which candidates are real addresses.
+var ipv6CandidatePattern = regexp.MustCompile(`[0-9A-Fa-f:]*:[0-9A-Fa-f:]*`)
+
+// ipv6Valid reports whether candidate is a valid IPv6 address.
+func ipv6Valid(candidate string) bool {
+	return net.ParseIP(candidate) != nil && strings.Contains(candidate, ":")
+}
+
+// IPv6Redactor replaces every IPv6 address with "::1", "::2", ..., numbered
+// by order of first appearance.
+func IPv6Redactor() Redactor {
+	return &regexRenumberRedactor{name: "IPv6Redactor", re: ipv6CandidatePattern, format: "::%d", validate: ipv6Valid}
+}
+
+var emailPattern = regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)
+
+// EmailRedactor replaces every email address with "user1@example.com",
+// "user2@example.com", ..., numbered by order of first appearance.
+func EmailRedactor() Redactor {
+	return &regexRenumberRedactor{name: "EmailRedactor", re: emailPattern, format: "user%d@example.com"}
+}
+
+var bearerTokenPattern = regexp.MustCompile(`Bearer\s+[A-Za-z0-9\-_.~+/]+=*|eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+
+// BearerTokenRedactor replaces every "Bearer <token>" header value and every
+// JWT-looking string (one starting with the base64url-encoded `{"` of a JWT
+// header, "eyJ") with "REDACTED-TOKEN-1", "REDACTED-TOKEN-2", ..., numbered
+// by order of first appearance.
+func BearerTokenRedactor() Redactor {
+	return &regexRenumberRedactor{name: "BearerTokenRedactor", re: bearerTokenPattern, format: "REDACTED-TOKEN-%d"}
+}
+
+// RegexRedactor compiles pattern on every Apply call (mirroring how
+// replaceUUIDs and replaceTimes already recompile their patterns) and
+// replaces all matches with replacement, which may use regexp submatch
+// references like "$1".
+func RegexRedactor(pattern, replacement string) Redactor {
+	return &regexRedactor{pattern: pattern, replacement: replacement}
+}
+
+type regexRedactor struct {
+	pattern     string
+	replacement string
+}
+
+func (r *regexRedactor) Name() string { return fmt.Sprintf("RegexRedactor(%s)", r.pattern) }
+
+func (r *regexRedactor) Apply(str string) (string, error) {
+	re, err := regexp.Compile(r.pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to compile regex pattern %q: %w", r.pattern, err)
+	}
+	return re.ReplaceAllString(str, r.replacement), nil
+}
+
+// CounterRedactor finds every match of pattern -- which must contain
+// exactly one capturing group wrapping a numeric/opaque id -- and replaces
+// each distinct captured value, in order of first appearance, with "1",
+// "2", ..., "N", leaving the rest of the match untouched. For example
+// CounterRedactor(`order_id:(\d+)`) turns "order_id:42 ... order_id:42"
+// into "order_id:1 ... order_id:1" and a later, different id into
+// "order_id:2".
+func CounterRedactor(pattern string) Redactor {
+	return &counterRedactor{pattern: pattern}
+}
+
+type counterRedactor struct {
+	pattern string
+}
+
+func (r *counterRedactor) Name() string { return fmt.Sprintf("CounterRedactor(%s)", r.pattern) }
+
+func (r *counterRedactor) Apply(str string) (string, error) {
+	re, err := regexp.Compile(r.pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to compile pattern %q: %w", r.pattern, err)
+	}
+	if re.NumSubexp() != 1 {
+		return "", fmt.Errorf("pattern %q must have exactly one capturing group around the id, has %d", r.pattern, re.NumSubexp())
+	}
+	seen := map[string]int{}
+	result := re.ReplaceAllStringFunc(str, func(match string) string {
+		loc := re.FindStringSubmatchIndex(match)
+		if loc == nil || loc[2] < 0 {
+			return match
+		}
+		captured := match[loc[2]:loc[3]]
+		n, ok := seen[captured]
+		if !ok {
+			n = len(seen) + 1
+			seen[captured] = n
+		}
+		return match[:loc[2]] + strconv.Itoa(n) + match[loc[3]:]
+	})
+	return result, nil
+}
+
+// JSONPathRedactor parses a buffer as JSON, replaces the value found at the
+// given RFC 6901 JSON Pointer path (e.g. "/data/attributes/createdAt") with
+// replacement, and re-serializes the result with the same indentation
+// CompareWithGolden uses. It is a no-op (beyond reformatting) if path
+// doesn't exist in the document, so it is safe to use across inputs where a
+// field is sometimes absent.
+func JSONPathRedactor(path, replacement string) Redactor {
+	return &jsonPathRedactor{path: path, replacement: replacement}
+}
+
+type jsonPathRedactor struct {
+	path        string
+	replacement string
+}
+
+func (r *jsonPathRedactor) Name() string {
+	return fmt.Sprintf("JSONPathRedactor(%s)", r.path)
+}
+
+func (r *jsonPathRedactor) Apply(str string) (string, error) {
+	return applyJSONPointerRedaction(str, r.path, r.replacement)
+}
@@ -0,0 +1,108 @@
+package golden
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPv4Redactor(t *testing.T) {
+	t.Parallel()
+	in := "connect to 192.168.1.10 then fall back to 10.0.0.5, then 192.168.1.10 again"
+	out, err := IPv4Redactor().Apply(in)
+	require.NoError(t, err)
+	require.Equal(t, "connect to 10.0.0.1 then fall back to 10.0.0.2, then 10.0.0.1 again", out)
+}
+
+func TestIPv6Redactor(t *testing.T) {
+	t.Parallel()
+	t.Run("compressed", func(t *testing.T) {
+		in := "connect to ::1 then fall back to fe80::1, then ::1 again"
+		out, err := IPv6Redactor().Apply(in)
+		require.NoError(t, err)
+		require.Equal(t, "connect to ::1 then fall back to ::2, then ::1 again", out)
+	})
+	t.Run("expanded", func(t *testing.T) {
+		in := "server at 2001:0db8:0000:0000:0000:ff00:0042:8329"
+		out, err := IPv6Redactor().Apply(in)
+		require.NoError(t, err)
+		require.Equal(t, "server at ::1", out)
+	})
+	t.Run("repeated distinct addresses", func(t *testing.T) {
+		in := "a=2001:db8::2 b=fe80::1 c=2001:db8::2"
+		out, err := IPv6Redactor().Apply(in)
+		require.NoError(t, err)
+		require.Equal(t, "a=::1 b=::2 c=::1", out)
+	})
+	t.Run("leaves non-addresses alone", func(t *testing.T) {
+		in := "no addresses here, just http://example.com:8080/path"
+		out, err := IPv6Redactor().Apply(in)
+		require.NoError(t, err)
+		require.Equal(t, in, out)
+	})
+}
+
+func TestEmailRedactor(t *testing.T) {
+	t.Parallel()
+	in := "from: alice@example.org to: bob@example.org cc: alice@example.org"
+	out, err := EmailRedactor().Apply(in)
+	require.NoError(t, err)
+	require.Equal(t, "from: user1@example.com to: user2@example.com cc: user1@example.com", out)
+}
+
+func TestBearerTokenRedactor(t *testing.T) {
+	t.Parallel()
+	in := `Authorization: Bearer abc.123-def_GHI`
+	out, err := BearerTokenRedactor().Apply(in)
+	require.NoError(t, err)
+	require.Equal(t, "Authorization: REDACTED-TOKEN-1", out)
+}
+
+func TestRegexRedactor(t *testing.T) {
+	t.Parallel()
+	out, err := RegexRedactor(`sk_live_[A-Za-z0-9]+`, "sk_live_REDACTED").Apply("key=sk_live_abcdef123 end")
+	require.NoError(t, err)
+	require.Equal(t, "key=sk_live_REDACTED end", out)
+
+	_, err = RegexRedactor(`(`, "x").Apply("anything")
+	require.Error(t, err)
+}
+
+func TestCounterRedactor(t *testing.T) {
+	t.Parallel()
+	out, err := CounterRedactor(`order_id:(\d+)`).Apply("order_id:42 ... order_id:42 ... order_id:7")
+	require.NoError(t, err)
+	require.Equal(t, "order_id:1 ... order_id:1 ... order_id:2", out)
+
+	_, err = CounterRedactor(`order_id:(\d+)-(\d+)`).Apply("order_id:1-2")
+	require.Error(t, err)
+}
+
+func TestJSONPathRedactor(t *testing.T) {
+	t.Parallel()
+	in := `{"data":{"id":"abc-123","attributes":{"createdAt":"2020-01-01T00:00:00Z"}}}`
+	out, err := JSONPathRedactor("/data/attributes/createdAt", "<redacted>").Apply(in)
+	require.NoError(t, err)
+	require.Contains(t, out, `"createdAt": "<redacted>"`)
+	require.Contains(t, out, `"id": "abc-123"`)
+
+	// Non-existent path is a no-op beyond reformatting.
+	out, err = JSONPathRedactor("/does/not/exist", "<redacted>").Apply(in)
+	require.NoError(t, err)
+	require.Contains(t, out, `"id": "abc-123"`)
+}
+
+func TestEffectiveRedactorsAndApply(t *testing.T) {
+	t.Parallel()
+	opts := CompareOptions{
+		UUIDAgnostic:     true,
+		DateTimeAgnostic: true,
+		Redactors:        []Redactor{RegexRedactor(`secret`, "REDACTED")},
+	}
+	redactors := effectiveRedactors(opts)
+	require.Len(t, redactors, 3)
+
+	out, err := applyRedactors("a secret value at 2020-01-01T00:00:00Z", redactors)
+	require.NoError(t, err)
+	require.Equal(t, "a REDACTED value at 0001-01-01T00:00:00Z", out)
+}
@@ -0,0 +1,334 @@
+package golden
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// txtarFile is a single named file inside a txtar archive.
+type txtarFile struct {
+	Name string
+	Data []byte
+}
+
+var (
+	txtarMarker    = []byte("-- ")
+	txtarMarkerEnd = []byte(" --")
+)
+
+// parseTxtar parses data in the txtar archive format used by this package:
+// an optional leading comment block, followed by zero or more sections each
+// introduced by a "-- name --" header line and running up to the next
+// header or the end of the archive. This is the same layout as the txtar
+// format used by gopls' rename/marker regression tests.
+func parseTxtar(data []byte) (comment string, files []txtarFile) {
+	var commentBytes []byte
+	var name string
+	commentBytes, name, data = txtarNextFile(data)
+	comment = string(commentBytes)
+	for name != "" {
+		curName := name
+		var body []byte
+		body, name, data = txtarNextFile(data)
+		files = append(files, txtarFile{Name: curName, Data: body})
+	}
+	return comment, files
+}
+
+// formatTxtar serializes comment and files back into the txtar archive
+// format. Every file's content is guaranteed to end in a newline so the
+// following "-- name --" marker always starts its own line.
+func formatTxtar(comment string, files []txtarFile) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(comment)
+	for _, f := range files {
+		fmt.Fprintf(&buf, "-- %s --\n", f.Name)
+		buf.Write(f.Data)
+		if len(f.Data) > 0 && !bytes.HasSuffix(f.Data, []byte("\n")) {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+// ensureTrailingNewline appends a newline to str if it is non-empty and
+// doesn't already end in one, matching the normalization formatTxtar applies
+// to every file it serializes.
+func ensureTrailingNewline(str string) string {
+	if len(str) > 0 && !strings.HasSuffix(str, "\n") {
+		return str + "\n"
+	}
+	return str
+}
+
+// txtarNextFile scans data for the next "-- name --" marker line, returning
+// everything before it, the (trimmed) name found in the marker, and
+// everything after the marker's newline. If no marker is found, name is "".
+func txtarNextFile(data []byte) (before []byte, name string, after []byte) {
+	i := 0
+	for {
+		if n, a, ok := txtarIsMarker(data[i:]); ok {
+			return data[:i], n, a
+		}
+		nl := bytes.IndexByte(data[i:], '\n')
+		if nl < 0 {
+			return data, "", nil
+		}
+		i += nl + 1
+	}
+}
+
+// txtarIsMarker reports whether data begins with a "-- name --" marker line
+// and, if so, returns the trimmed name and the remainder of data after the
+// marker's line.
+func txtarIsMarker(data []byte) (name string, after []byte, ok bool) {
+	if !bytes.HasPrefix(data, txtarMarker) {
+		return "", nil, false
+	}
+	line := data
+	if nl := bytes.IndexByte(data, '\n'); nl >= 0 {
+		line, after = data[:nl], data[nl+1:]
+	} else {
+		after = nil
+	}
+	if !bytes.HasSuffix(line, txtarMarkerEnd) || len(line) < len(txtarMarker)+len(txtarMarkerEnd) {
+		return "", nil, false
+	}
+	name = strings.TrimSpace(string(line[len(txtarMarker) : len(line)-len(txtarMarkerEnd)]))
+	return name, after, true
+}
+
+// CompareDirWithGolden walks dir, applies opts.IncludeGlobs/opts.ExcludeGlobs
+// and opts.UUIDAgnostic/opts.DateTimeAgnostic redaction to every file it
+// finds, and compares the resulting set of files against a single
+// txtar-formatted golden archive stored at goldenTxtarFile. It is the
+// multi-file sibling of CompareWithGolden, useful for golden-testing code
+// generators, scaffolders, or migration tools that emit many files at once.
+// On -update, the archive is (re)written from the directory's current
+// contents.
+func CompareDirWithGolden(t *testing.T, goldenTxtarFile string, dir string, opts CompareOptions) {
+	files, err := collectDirFiles(dir, opts.IncludeGlobs, opts.ExcludeGlobs)
+	if err != nil {
+		t.Fatal(fmt.Errorf("failed to collect files from directory %q: %w", dir, err))
+	}
+	if err := testableCompareFilesWithGolden(*updateGoldenFiles, goldenTxtarFile, files, opts); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// CompareFilesWithGolden compares the given in-memory files (keyed by a
+// slash-separated relative path) against a single txtar-formatted golden
+// archive stored at goldenTxtarFile. It behaves like CompareDirWithGolden
+// but lets the caller hand over already-generated file contents instead of
+// having them read from disk.
+func CompareFilesWithGolden(t *testing.T, goldenTxtarFile string, files map[string][]byte, opts CompareOptions) {
+	if err := testableCompareFilesWithGolden(*updateGoldenFiles, goldenTxtarFile, files, opts); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testableCompareFilesWithGolden(update bool, goldenTxtarFile string, files map[string][]byte, opts CompareOptions) error {
+	absPath, err := filepath.Abs(goldenTxtarFile)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path for %q: %w", goldenTxtarFile, err)
+	}
+
+	redactors := effectiveRedactors(opts)
+	redacted := map[string]string{}
+	for name, data := range files {
+		str, err := applyRedactors(string(data), redactors)
+		if err != nil {
+			return fmt.Errorf("failed to redact %q: %w", name, err)
+		}
+		// formatTxtar always ends a file's content in a newline so the
+		// following "-- name --" marker starts its own line; normalize here
+		// too so a file round-tripped through the golden archive compares
+		// equal to the in-memory content it was generated from.
+		redacted[name] = ensureTrailingNewline(str)
+	}
+
+	if update {
+		mu := lockForPath(absPath)
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err := checkUpdateAllowed(); err != nil {
+			return err
+		}
+
+		names := make([]string, 0, len(redacted))
+		for name := range redacted {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		archiveFiles := make([]txtarFile, 0, len(names))
+		for _, name := range names {
+			archiveFiles = append(archiveFiles, txtarFile{Name: name, Data: []byte(redacted[name])})
+		}
+
+		if err := os.MkdirAll(filepath.Dir(absPath), os.FileMode(0777)); err != nil {
+			return fmt.Errorf("failed to create directory (and potential parent dirs) to write golden archive to: %w", err)
+		}
+		if err := atomicWriteFile(absPath, formatTxtar("", archiveFiles), goldenFileMode(opts)); err != nil {
+			return fmt.Errorf("failed to update golden archive %q: %w", absPath, err)
+		}
+	}
+
+	archiveData, err := ioutil.ReadFile(absPath)
+	if err != nil {
+		return fmt.Errorf("failed to read golden archive %q: %w", absPath, err)
+	}
+	_, goldenFiles := parseTxtar(archiveData)
+	golden := map[string]string{}
+	for _, f := range goldenFiles {
+		golden[f.Name] = string(f.Data)
+	}
+
+	var missing, extra, common []string
+	for name := range golden {
+		if _, ok := redacted[name]; !ok {
+			missing = append(missing, name)
+		} else {
+			common = append(common, name)
+		}
+	}
+	for name := range redacted {
+		if _, ok := golden[name]; !ok {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+	sort.Strings(common)
+
+	var msgs []string
+	if len(missing) > 0 {
+		msgs = append(msgs, fmt.Sprintf("missing files (present in golden archive but not produced): %v", missing))
+	}
+	if len(extra) > 0 {
+		msgs = append(msgs, fmt.Sprintf("extra files (produced but not present in golden archive): %v", extra))
+	}
+	for _, name := range common {
+		if golden[name] != redacted[name] {
+			msgs = append(msgs, fmt.Sprintf("mismatch in %q:\n %s \n", name, diffText(golden[name], redacted[name])))
+		}
+	}
+
+	if len(msgs) > 0 {
+		return fmt.Errorf("mismatch of actual files and golden archive %q:\n%s", absPath, strings.Join(msgs, "\n"))
+	}
+	return nil
+}
+
+// collectDirFiles walks dir and reads every regular file whose path
+// (relative to dir) passes the include/exclude glob filters, returning them
+// keyed by their slash-separated relative path.
+func collectDirFiles(dir string, includes, excludes []string) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return fmt.Errorf("failed to compute path of %q relative to %q: %w", path, dir, err)
+		}
+		rel = filepath.ToSlash(rel)
+		ok, err := pathIncluded(rel, includes, excludes)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %q: %w", path, err)
+		}
+		files[rel] = data
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// pathIncluded reports whether rel (a slash-separated relative path) should
+// be kept given an optional allowlist of include globs and a denylist of
+// exclude globs. An empty includes list means everything is included by
+// default; excludes are then applied on top.
+func pathIncluded(rel string, includes, excludes []string) (bool, error) {
+	if len(includes) > 0 {
+		included := false
+		for _, pattern := range includes {
+			ok, err := matchGlob(pattern, rel)
+			if err != nil {
+				return false, fmt.Errorf("invalid include glob %q: %w", pattern, err)
+			}
+			if ok {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false, nil
+		}
+	}
+	for _, pattern := range excludes {
+		ok, err := matchGlob(pattern, rel)
+		if err != nil {
+			return false, fmt.Errorf("invalid exclude glob %q: %w", pattern, err)
+		}
+		if ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// matchGlob reports whether name (a slash-separated path) matches pattern, a
+// glob over path segments where "**" additionally stands for any number of
+// segments (including zero), so "**/*.go" matches "foo.go" as well as
+// "a/b/foo.go". Individual segments are matched with filepath.Match.
+func matchGlob(pattern, name string) (bool, error) {
+	return matchGlobParts(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchGlobParts(pattern, name []string) (bool, error) {
+	if len(pattern) == 0 {
+		return len(name) == 0, nil
+	}
+	if pattern[0] == "**" {
+		if len(pattern) == 1 {
+			return true, nil
+		}
+		for i := 0; i <= len(name); i++ {
+			ok, err := matchGlobParts(pattern[1:], name[i:])
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	if len(name) == 0 {
+		return false, nil
+	}
+	ok, err := filepath.Match(pattern[0], name[0])
+	if err != nil || !ok {
+		return false, err
+	}
+	return matchGlobParts(pattern[1:], name[1:])
+}
@@ -0,0 +1,96 @@
+package golden
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxtarParseAndFormatRoundtrip(t *testing.T) {
+	t.Parallel()
+	archive := []byte("this is a comment\n-- a.txt --\nhello\n-- dir/b.txt --\nworld\n")
+	comment, files := parseTxtar(archive)
+	require.Equal(t, "this is a comment\n", comment)
+	require.Equal(t, []txtarFile{
+		{Name: "a.txt", Data: []byte("hello\n")},
+		{Name: "dir/b.txt", Data: []byte("world\n")},
+	}, files)
+	require.Equal(t, archive, formatTxtar(comment, files))
+}
+
+func TestTxtarFormatAddsMissingTrailingNewline(t *testing.T) {
+	t.Parallel()
+	out := formatTxtar("", []txtarFile{{Name: "a.txt", Data: []byte("no newline")}})
+	require.Equal(t, "-- a.txt --\nno newline\n", string(out))
+}
+
+func TestMatchGlob(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*.go", "foo.go", true},
+		{"*.go", "a/foo.go", false},
+		{"**/*.go", "foo.go", true},
+		{"**/*.go", "a/b/foo.go", true},
+		{"a/**/c.go", "a/c.go", true},
+		{"a/**/c.go", "a/b/c.go", true},
+		{"a/**/c.go", "a/b/x/c.go", true},
+		{"a/**/c.go", "a/b/x/d.go", false},
+	}
+	for _, c := range cases {
+		ok, err := matchGlob(c.pattern, c.name)
+		require.NoError(t, err)
+		require.Equal(t, c.want, ok, "pattern %q against %q", c.pattern, c.name)
+	}
+}
+
+func TestCompareFilesWithGoldenFile(t *testing.T) {
+	t.Run("update then compare", func(t *testing.T) {
+		f, err := ioutil.TempFile(".", "golden-archive-*.txtar")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		require.NoError(t, os.Remove(f.Name()))
+		defer os.Remove(f.Name())
+
+		files := map[string][]byte{
+			"main.go":   []byte("package main\n"),
+			"README.md": []byte("# demo"),
+		}
+
+		err = testableCompareFilesWithGolden(true, f.Name(), files, CompareOptions{})
+		require.NoError(t, err)
+
+		err = testableCompareFilesWithGolden(false, f.Name(), files, CompareOptions{})
+		require.NoError(t, err)
+	})
+
+	t.Run("detects missing, extra and mismatched files", func(t *testing.T) {
+		f, err := ioutil.TempFile(".", "golden-archive-*.txtar")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		require.NoError(t, os.Remove(f.Name()))
+		defer os.Remove(f.Name())
+
+		err = testableCompareFilesWithGolden(true, f.Name(), map[string][]byte{
+			"a.txt": []byte("one"),
+			"b.txt": []byte("two"),
+		}, CompareOptions{})
+		require.NoError(t, err)
+
+		err = testableCompareFilesWithGolden(false, f.Name(), map[string][]byte{
+			"a.txt": []byte("one but different"),
+			"c.txt": []byte("three"),
+		}, CompareOptions{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "missing files")
+		require.Contains(t, err.Error(), "b.txt")
+		require.Contains(t, err.Error(), "extra files")
+		require.Contains(t, err.Error(), "c.txt")
+		require.Contains(t, err.Error(), `mismatch in "a.txt"`)
+	})
+}
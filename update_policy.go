@@ -0,0 +1,132 @@
+package golden
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Policy decides whether a -update write to a golden file is allowed.
+type Policy interface {
+	// check returns an error explaining why the write is rejected, or nil
+	// to allow it.
+	check() error
+}
+
+type policyFunc func() error
+
+func (f policyFunc) check() error { return f() }
+
+// UpdateAlways permits -update writes unconditionally. This is the default.
+var UpdateAlways Policy = policyFunc(func() error { return nil })
+
+// UpdateNeverInCI permits -update writes everywhere except when run under
+// CI (detected via the CI or GITHUB_ACTIONS environment variables being set
+// to "true"), where it fails instead of silently rewriting golden files.
+var UpdateNeverInCI Policy = policyFunc(func() error {
+	if os.Getenv("CI") == "true" || os.Getenv("GITHUB_ACTIONS") == "true" {
+		return fmt.Errorf("refusing to update golden files in CI (CI=true or GITHUB_ACTIONS=true is set); update them locally with -update instead")
+	}
+	return nil
+})
+
+// UpdateRequireEnv permits -update writes only when the environment
+// variable named by the "KEY=VALUE" assignment is set to that exact value,
+// e.g. UpdateRequireEnv("GOLDEN_UPDATE=1").
+func UpdateRequireEnv(assignment string) Policy {
+	parts := strings.SplitN(assignment, "=", 2)
+	key := parts[0]
+	want := ""
+	if len(parts) == 2 {
+		want = parts[1]
+	}
+	return policyFunc(func() error {
+		if os.Getenv(key) != want {
+			return fmt.Errorf("refusing to update golden files: environment variable %q is not set to %q", key, want)
+		}
+		return nil
+	})
+}
+
+var (
+	updatePolicyMu sync.Mutex
+	updatePolicy   Policy = UpdateAlways
+)
+
+// SetUpdatePolicy controls whether -update is allowed to (re)write golden
+// files for the remainder of the test binary's run. The default is
+// UpdateAlways.
+func SetUpdatePolicy(p Policy) {
+	updatePolicyMu.Lock()
+	defer updatePolicyMu.Unlock()
+	updatePolicy = p
+}
+
+func checkUpdateAllowed() error {
+	updatePolicyMu.Lock()
+	p := updatePolicy
+	updatePolicyMu.Unlock()
+	return p.check()
+}
+
+// defaultGoldenFileMode is used whenever CompareOptions.FileMode is left at
+// its zero value.
+const defaultGoldenFileMode = os.FileMode(0644)
+
+// goldenFileMode returns opts.FileMode, or defaultGoldenFileMode if unset.
+func goldenFileMode(opts CompareOptions) os.FileMode {
+	if opts.FileMode == 0 {
+		return defaultGoldenFileMode
+	}
+	return opts.FileMode
+}
+
+var (
+	pathLocksMu sync.Mutex
+	pathLocks   = map[string]*sync.Mutex{}
+)
+
+// lockForPath returns a mutex private to path, creating it on first use, so
+// that concurrent t.Parallel() tests writing the same golden file (or the
+// sibling files of a txtar archive) are serialized instead of racing.
+func lockForPath(path string) *sync.Mutex {
+	pathLocksMu.Lock()
+	defer pathLocksMu.Unlock()
+	mu, ok := pathLocks[path]
+	if !ok {
+		mu = &sync.Mutex{}
+		pathLocks[path] = mu
+	}
+	return mu
+}
+
+// atomicWriteFile writes data to path by first writing it to a temporary
+// file in the same directory and then renaming it into place, so a test
+// binary crashing mid-write never leaves a truncated golden file behind.
+func atomicWriteFile(path string, data []byte, mode os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := ioutil.TempFile(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file for atomic write to %q: %w", path, err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // no-op once the rename below succeeds
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temp file %q: %w", tmpPath, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file %q: %w", tmpPath, err)
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return fmt.Errorf("failed to set mode on temp file %q: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to rename temp file %q to %q: %w", tmpPath, path, err)
+	}
+	return nil
+}
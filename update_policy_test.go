@@ -0,0 +1,83 @@
+package golden
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateNeverInCI(t *testing.T) {
+	old, hadOld := os.LookupEnv("CI")
+	defer func() {
+		if hadOld {
+			os.Setenv("CI", old)
+		} else {
+			os.Unsetenv("CI")
+		}
+	}()
+
+	require.NoError(t, os.Unsetenv("CI"))
+	require.NoError(t, UpdateNeverInCI.check())
+
+	require.NoError(t, os.Setenv("CI", "true"))
+	require.Error(t, UpdateNeverInCI.check())
+}
+
+func TestUpdateRequireEnv(t *testing.T) {
+	const key = "GOLDEN_UPDATE_TEST"
+	defer os.Unsetenv(key)
+
+	policy := UpdateRequireEnv(key + "=1")
+
+	require.NoError(t, os.Unsetenv(key))
+	require.Error(t, policy.check())
+
+	require.NoError(t, os.Setenv(key, "1"))
+	require.NoError(t, policy.check())
+
+	require.NoError(t, os.Setenv(key, "0"))
+	require.Error(t, policy.check())
+}
+
+func TestSetUpdatePolicyIsEnforced(t *testing.T) {
+	defer SetUpdatePolicy(UpdateAlways)
+
+	f, err := ioutil.TempFile(".", "policy-*.golden")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.NoError(t, os.Remove(f.Name()))
+	defer os.Remove(f.Name())
+
+	SetUpdatePolicy(policyFunc(func() error { return fmt.Errorf("updates disabled for this test") }))
+	err = testableCompare(true, f.Name(), "hello", CompareOptions{})
+	require.Error(t, err)
+	_, statErr := os.Stat(f.Name())
+	require.True(t, os.IsNotExist(statErr), "golden file must not have been written when the policy rejects the update")
+}
+
+func TestAtomicWriteFileReplacesExistingContent(t *testing.T) {
+	f, err := ioutil.TempFile(".", "atomic-*.golden")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	defer os.Remove(f.Name())
+
+	require.NoError(t, atomicWriteFile(f.Name(), []byte("first"), 0644))
+	require.NoError(t, atomicWriteFile(f.Name(), []byte("second"), 0644))
+
+	data, err := ioutil.ReadFile(f.Name())
+	require.NoError(t, err)
+	require.Equal(t, "second", string(data))
+
+	info, err := os.Stat(f.Name())
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0644), info.Mode().Perm())
+}
+
+func TestLockForPathReturnsSameMutexForSamePath(t *testing.T) {
+	a := lockForPath("/tmp/same/path")
+	b := lockForPath("/tmp/same/path")
+	require.Same(t, a, b)
+}